@@ -0,0 +1,157 @@
+// Package simmock replays a scripted sequence of SimConnect dispatch
+// messages from a JSON fixture. It satisfies the same method set vfrmap's
+// dispatch loop uses against *simconnect.SimConnect, so contributors on
+// non-Windows machines (and CI) can exercise the websocket broadcaster,
+// the HTTP handler and the data-definition plumbing without a running
+// copy of MSFS.
+package simmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/lian/msfs2020-go/simconnect"
+)
+
+// Frame is a single scripted dispatch message. Exactly one of Report or
+// Exception should be set: Report frames are replayed as
+// RECV_ID_SIMOBJECT_DATA_BYTYPE against the struct passed to
+// RegisterDataDefinition, matching values by their `name` struct tag;
+// Exception frames are replayed as RECV_ID_EXCEPTION.
+type Frame struct {
+	Report    map[string]float64 `json:"report,omitempty"`
+	Exception simconnect.DWORD   `json:"exception,omitempty"`
+	DelayMS   int                `json:"delay_ms,omitempty"`
+}
+
+// Client is a mock SimClient that replays frames loaded from a fixture
+// file instead of talking to a running copy of MSFS.
+type Client struct {
+	frames []Frame
+
+	mu         sync.Mutex
+	pos        int
+	defineID   simconnect.DWORD
+	requestID  simconnect.DWORD
+	reportType reflect.Type
+}
+
+// New loads a fixture of scripted frames from path (JSON) and returns a
+// Client ready to be polled with GetNextDispatch.
+func New(path string) (*Client, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []Frame
+	if err := json.Unmarshal(buf, &frames); err != nil {
+		return nil, fmt.Errorf("simmock: invalid fixture %s: %s", path, err)
+	}
+
+	return &Client{frames: frames}, nil
+}
+
+// RegisterDataDefinition remembers the concrete type of data (e.g.
+// *main.Report) so later frames can be unmarshalled into fresh instances
+// of it.
+func (c *Client) RegisterDataDefinition(data interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reportType = reflect.TypeOf(data).Elem()
+	c.defineID++
+	return nil
+}
+
+// GetDefineID returns the mock define id assigned in RegisterDataDefinition.
+func (c *Client) GetDefineID(data interface{}) simconnect.DWORD {
+	return c.defineID
+}
+
+// RequestDataOnSimObjectType just remembers requestID; the mock already
+// knows which struct to fill in from RegisterDataDefinition.
+func (c *Client) RequestDataOnSimObjectType(requestID, defineID simconnect.DWORD, radiusMeters uint32, simObjectType simconnect.DWORD) {
+	c.mu.Lock()
+	c.requestID = requestID
+	c.mu.Unlock()
+}
+
+// Close is a no-op for the mock.
+func (c *Client) Close() error {
+	return nil
+}
+
+// GetNextDispatch blocks for the scripted frame's delay (if any) and
+// returns the next frame in the same shape
+// *simconnect.SimConnect.GetNextDispatch would: a pointer to a
+// SIMCONNECT_RECV-compatible struct and an HRESULT-ish status code.
+func (c *Client) GetNextDispatch() (unsafe.Pointer, uintptr, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pos >= len(c.frames) {
+		return nil, uintptr(simconnect.E_FAIL), nil
+	}
+
+	frame := c.frames[c.pos]
+	c.pos++
+
+	if frame.DelayMS > 0 {
+		time.Sleep(time.Duration(frame.DelayMS) * time.Millisecond)
+	}
+
+	if frame.Exception != 0 {
+		recv := &simconnect.RecvException{
+			Recv:      simconnect.Recv{ID: simconnect.RECV_ID_EXCEPTION},
+			Exception: frame.Exception,
+		}
+		return unsafe.Pointer(recv), 0, nil
+	}
+
+	return c.reportPointer(frame.Report), 0, nil
+}
+
+// reportPointer allocates a fresh instance of the struct registered via
+// RegisterDataDefinition, fills the SIMCONNECT_RECV_SIMOBJECT_DATA_BYTYPE
+// header and copies report values onto fields by matching their `name`
+// struct tag, then returns it as the raw pointer GetNextDispatch hands
+// back to the dispatch loop.
+func (c *Client) reportPointer(report map[string]float64) unsafe.Pointer {
+	if c.reportType == nil {
+		return nil
+	}
+
+	instance := reflect.New(c.reportType)
+	elem := instance.Elem()
+
+	header := elem.Field(0).Addr().Interface().(*simconnect.RecvSimobjectDataByType)
+	header.ID = simconnect.RECV_ID_SIMOBJECT_DATA_BYTYPE
+	header.RequestID = c.requestID
+	header.DefineID = c.defineID
+
+	for i := 1; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+		name := strings.TrimSpace(field.Tag.Get("name"))
+		if name == "" {
+			continue
+		}
+
+		value, ok := report[name]
+		if !ok {
+			continue
+		}
+
+		if elem.Field(i).Kind() == reflect.Float64 {
+			elem.Field(i).SetFloat(value)
+		}
+	}
+
+	return unsafe.Pointer(instance.Pointer())
+}
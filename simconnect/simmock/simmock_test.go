@@ -0,0 +1,67 @@
+package simmock
+
+import (
+	"testing"
+
+	"github.com/lian/msfs2020-go/simconnect"
+)
+
+type fixtureReport struct {
+	simconnect.RecvSimobjectDataByType
+	Latitude  float64 `name:"PLANE LATITUDE"`
+	Longitude float64 `name:"PLANE LONGITUDE"`
+	Altitude  float64 `name:"INDICATED ALTITUDE"`
+	Airspeed  float64 `name:"AIRSPEED INDICATED"`
+}
+
+func TestReplaysScriptedReports(t *testing.T) {
+	c, err := New("testdata/flight.json")
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	if err := c.RegisterDataDefinition(&fixtureReport{}); err != nil {
+		t.Fatalf("RegisterDataDefinition: %s", err)
+	}
+	c.RequestDataOnSimObjectType(c.GetDefineID(&fixtureReport{}), c.GetDefineID(&fixtureReport{}), 0, 0)
+
+	ppData, _, err := c.GetNextDispatch()
+	if err != nil {
+		t.Fatalf("GetNextDispatch: %s", err)
+	}
+	report := (*fixtureReport)(ppData)
+	if report.ID != simconnect.RECV_ID_SIMOBJECT_DATA_BYTYPE {
+		t.Fatalf("expected RECV_ID_SIMOBJECT_DATA_BYTYPE, got %v", report.ID)
+	}
+	if report.Latitude != 47.449 {
+		t.Fatalf("expected latitude 47.449, got %v", report.Latitude)
+	}
+	if report.Airspeed != 90 {
+		t.Fatalf("expected airspeed 90, got %v", report.Airspeed)
+	}
+
+	ppData, _, err = c.GetNextDispatch()
+	if err != nil {
+		t.Fatalf("GetNextDispatch (2nd): %s", err)
+	}
+	report = (*fixtureReport)(ppData)
+	if report.Longitude != -122.311 {
+		t.Fatalf("expected longitude -122.311, got %v", report.Longitude)
+	}
+
+	ppData, _, err = c.GetNextDispatch()
+	if err != nil {
+		t.Fatalf("GetNextDispatch (3rd): %s", err)
+	}
+	exc := (*simconnect.RecvException)(ppData)
+	if exc.ID != simconnect.RECV_ID_EXCEPTION {
+		t.Fatalf("expected RECV_ID_EXCEPTION, got %v", exc.ID)
+	}
+	if exc.Exception != 3 {
+		t.Fatalf("expected exception code 3, got %v", exc.Exception)
+	}
+
+	if _, r1, err := c.GetNextDispatch(); err != nil || uintptr(r1) != uintptr(simconnect.E_FAIL) {
+		t.Fatalf("expected E_FAIL once the fixture is exhausted, got r1=%v err=%v", r1, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lian/msfs2020-go/simconnect"
+	"github.com/lian/msfs2020-go/vfrmap/tracklog"
+	"github.com/lian/msfs2020-go/vfrmap/websockets"
+)
+
+// errNoDispatch is returned by handleDispatch when GetNextDispatch reports
+// E_FAIL, SimConnect's way of saying no message is available yet.
+var errNoDispatch = errors.New("vfrmap: no dispatch message available")
+
+// handleDispatch processes exactly one message off s's dispatch queue,
+// broadcasting a position/track update and recording a track point when
+// it's a report for reportDefineID. It returns the possibly-replaced
+// report, since SimConnect hands back a pointer into its own buffer, so
+// the caller just keeps passing the result back in on the next call.
+func handleDispatch(s SimClient, ws *websockets.Websockets, recorder *tracklog.Recorder, reportDefineID simconnect.DWORD, report *Report, verbose bool) (*Report, error) {
+	ppData, r1, err := s.GetNextDispatch()
+
+	// r1 is an HRESULT packed into a uintptr, so it's never negative; the
+	// only failure this loop has ever had to handle is E_FAIL, meaning no
+	// message is ready yet.
+	switch {
+	case uint32(r1) == simconnect.E_FAIL:
+		return report, errNoDispatch
+	case r1 != 0:
+		return report, fmt.Errorf("GetNextDispatch error: %d %s", r1, err)
+	}
+
+	recvInfo := *(*simconnect.Recv)(ppData)
+
+	switch recvInfo.ID {
+	case simconnect.RECV_ID_EXCEPTION:
+		recvErr := *(*simconnect.RecvException)(ppData)
+		fmt.Printf("SIMCONNECT_RECV_ID_EXCEPTION %#v\n", recvErr)
+
+	case simconnect.RECV_ID_OPEN:
+		recvOpen := *(*simconnect.RecvOpen)(ppData)
+		fmt.Println("SIMCONNECT_RECV_ID_OPEN", fmt.Sprintf("%s", recvOpen.ApplicationName))
+
+	case simconnect.RECV_ID_EVENT:
+		recvEvent := *(*simconnect.RecvEvent)(ppData)
+		fmt.Println("SIMCONNECT_RECV_ID_EVENT")
+
+		switch recvEvent.EventID {
+		default:
+			fmt.Println("unknown SIMCONNECT_RECV_ID_EVENT", recvEvent.EventID)
+		}
+
+	case simconnect.RECV_ID_SIMOBJECT_DATA_BYTYPE:
+		recvData := *(*simconnect.RecvSimobjectDataByType)(ppData)
+
+		switch recvData.RequestID {
+		case reportDefineID:
+			report = (*Report)(ppData)
+
+			if verbose {
+				fmt.Printf("REPORT: %#v\n", report)
+			}
+
+			ws.Broadcast("position", map[string]interface{}{
+				"latitude":       report.Latitude,
+				"longitude":      report.Longitude,
+				"altitude":       fmt.Sprintf("%.0f", report.Altitude),
+				"heading":        int(report.Heading),
+				"airspeed":       fmt.Sprintf("%.0f", report.Airspeed),
+				"vertical_speed": fmt.Sprintf("%.0f", report.VerticalSpeed),
+				"flaps":          fmt.Sprintf("%.0f", report.Flaps),
+				"trim":           fmt.Sprintf("%.1f", report.Trim),
+				"rudder_trim":    fmt.Sprintf("%.1f", report.RudderTrim),
+			})
+
+			if err := recorder.Record(tracklog.Point{
+				Time:      time.Now(),
+				Latitude:  report.Latitude,
+				Longitude: report.Longitude,
+				Altitude:  report.Altitude,
+				Heading:   report.Heading,
+				Airspeed:  report.Airspeed,
+			}); err != nil {
+				fmt.Println("tracklog: failed to record point:", err)
+			} else {
+				track := recorder.Current()
+				ws.Broadcast("track", map[string]interface{}{
+					"track_id":     track.ID,
+					"track_points": len(track.Points),
+				})
+			}
+
+			report.RequestData(s)
+		}
+
+	default:
+		fmt.Println("recvInfo.ID unknown", recvInfo.ID)
+	}
+
+	return report, nil
+}
@@ -5,18 +5,20 @@ package main
 // build: GOOS=windows GOARCH=amd64 go build -o vfrmap.exe github.com/lian/msfs2020-go/vfrmap
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
+	"golang.org/x/crypto/acme/autocert"
+
 	"github.com/lian/msfs2020-go/simconnect"
+	"github.com/lian/msfs2020-go/simconnect/simmock"
+	"github.com/lian/msfs2020-go/vfrmap/relay"
+	"github.com/lian/msfs2020-go/vfrmap/tracklog"
 	"github.com/lian/msfs2020-go/vfrmap/websockets"
 )
 
@@ -34,7 +36,7 @@ type Report struct {
 	RudderTrim    float64   `name:"RUDDER TRIM PCT" unit:"percent"`
 }
 
-func (r *Report) RequestData(s *simconnect.SimConnect) {
+func (r *Report) RequestData(s SimClient) {
 	defineID := s.GetDefineID(r)
 	requestID := defineID
 	s.RequestDataOnSimObjectType(requestID, defineID, 0, simconnect.SIMOBJECT_TYPE_USER)
@@ -48,14 +50,37 @@ var verbose bool
 var httpListen string
 var mapApiKeyDefault string
 var mapApiKey string
+var mockFile string
+var tlsCert string
+var tlsKey string
+var autocertDomain string
+var autocertCache string
+var trackStoreURL string
+var relayMode bool
+var relayToken string
+var relayUpstream string
 
 func main() {
 	flag.BoolVar(&showVersion, "v", false, "version")
 	flag.BoolVar(&verbose, "verbose", false, "verbose output")
 	flag.StringVar(&httpListen, "listen", "0.0.0.0:9000", "http listen")
 	flag.StringVar(&mapApiKey, "api-key", "", "gmap api-key")
+	flag.StringVar(&mockFile, "mock", "", "replay a scripted simconnect fixture instead of connecting to MSFS (see simconnect/simmock)")
+	flag.StringVar(&tlsCert, "tls-cert", "", "tls certificate (PEM) for https, requires -tls-key")
+	flag.StringVar(&tlsKey, "tls-key", "", "tls private key (PEM) for https, requires -tls-cert")
+	flag.StringVar(&autocertDomain, "autocert-domain", "", "request a Let's Encrypt certificate for this domain and serve https instead of -tls-cert/-tls-key")
+	flag.StringVar(&autocertCache, "autocert-cache", "autocert-cache", "directory to cache Let's Encrypt account/certificate data in")
+	flag.StringVar(&trackStoreURL, "track-store", "local:./tracklog", "where to persist flight tracks: local:/path, s3://bucket/prefix, swift://container/prefix, azure://container/prefix")
+	flag.BoolVar(&relayMode, "relay", false, "don't connect to MSFS, just accept an upstream relay link and fan it out to downstream clients")
+	flag.StringVar(&relayToken, "relay-token", "", "shared secret the upstream relay link (or -relay-upstream) authenticates with")
+	flag.StringVar(&relayUpstream, "relay-upstream", "", "wss://host/relay URL of a -relay server to also publish this instance's broadcasts to")
 	flag.Parse()
 
+	if (tlsCert == "") != (tlsKey == "") {
+		fmt.Println("-tls-cert and -tls-key must both be set, or both left empty")
+		os.Exit(2)
+	}
+
 	if showVersion {
 		fmt.Printf("version: %s (%s)\n", buildVersion, buildTime)
 		return
@@ -71,19 +96,51 @@ func main() {
 
 	ws := websockets.New()
 
-	s, err := simconnect.New("VFR Map")
-	if err != nil {
-		panic(err)
+	if relayUpstream != "" {
+		upstream := relay.NewUpstream(relayUpstream, relayToken)
+		go upstream.Run(ws.Broadcasts, nil)
 	}
-	fmt.Println("Connected to Flight Simulator!")
 
+	var s SimClient
+	var recorder *tracklog.Recorder
+	var reportDefineID simconnect.DWORD
 	report := &Report{}
-	err = s.RegisterDataDefinition(report)
-	if err != nil {
-		panic(err)
-	}
 
-	report.RequestData(s)
+	if relayMode {
+		fmt.Println("relay mode: fanning out whatever arrives on /relay, not connecting to MSFS")
+	} else {
+		var err error
+		if mockFile != "" {
+			s, err = simmock.New(mockFile)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println("replaying mock simconnect fixture:", mockFile)
+		} else {
+			s, err = simconnect.New("VFR Map")
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println("Connected to Flight Simulator!")
+		}
+
+		err = s.RegisterDataDefinition(report)
+		if err != nil {
+			panic(err)
+		}
+		reportDefineID = s.GetDefineID(report)
+
+		report.RequestData(s)
+
+		trackStore, err := tracklog.NewStore(trackStoreURL)
+		if err != nil {
+			panic(err)
+		}
+		recorder = tracklog.NewRecorder(trackStore)
+		if err := recorder.Open(); err != nil {
+			panic(err)
+		}
+	}
 
 	/*
 		fmt.Println("SubscribeToSystemEvent")
@@ -91,108 +148,55 @@ func main() {
 		s.SubscribeToSystemEvent(eventSimStartID, "SimStart")
 	*/
 
-	go func() {
-		for {
-			ppData, r1, err := s.GetNextDispatch()
-
-			if r1 < 0 {
-				if uint32(r1) == simconnect.E_FAIL {
-					// skip error, means no new messages?
-					continue
-				} else {
-					panic(fmt.Errorf("GetNextDispatch error: %d %s", r1, err))
+	if !relayMode {
+		go func() {
+			for {
+				var err error
+				report, err = handleDispatch(s, ws, recorder, reportDefineID, report, verbose)
+				if err != nil && err != errNoDispatch {
+					panic(err)
 				}
+				time.Sleep(100 * time.Millisecond)
 			}
+		}()
+	}
 
-			recvInfo := *(*simconnect.Recv)(ppData)
-			//fmt.Println(ppData, pcbData, recvInfo)
-
-			switch recvInfo.ID {
-			case simconnect.RECV_ID_EXCEPTION:
-				recvErr := *(*simconnect.RecvException)(ppData)
-				fmt.Printf("SIMCONNECT_RECV_ID_EXCEPTION %#v\n", recvErr)
-
-			case simconnect.RECV_ID_OPEN:
-				recvOpen := *(*simconnect.RecvOpen)(ppData)
-				fmt.Println("SIMCONNECT_RECV_ID_OPEN", fmt.Sprintf("%s", recvOpen.ApplicationName))
-				//spew.Dump(recvOpen)
-			case simconnect.RECV_ID_EVENT:
-				recvEvent := *(*simconnect.RecvEvent)(ppData)
-				fmt.Println("SIMCONNECT_RECV_ID_EVENT")
-				//spew.Dump(recvEvent)
-
-				switch recvEvent.EventID {
-				//case eventSimStartID:
-				//	s.RequestDataOnSimObjectType(requestID, defineID, 0, simconnect.SIMOBJECT_TYPE_USER)
-				default:
-					fmt.Println("unknown SIMCONNECT_RECV_ID_EVENT", recvEvent.EventID)
-				}
-
-			case simconnect.RECV_ID_SIMOBJECT_DATA_BYTYPE:
-				recvData := *(*simconnect.RecvSimobjectDataByType)(ppData)
-				//fmt.Println("SIMCONNECT_RECV_SIMOBJECT_DATA_BYTYPE")
-
-				switch recvData.RequestID {
-				case s.DefineMap["Report"]:
-					report = (*Report)(ppData)
-
-					if verbose {
-						fmt.Printf("REPORT: %#v\n", report)
-					}
-
-					ws.Broadcast(map[string]interface{}{
-						"latitude":       report.Latitude,
-						"longitude":      report.Longitude,
-						"altitude":       fmt.Sprintf("%.0f", report.Altitude),
-						"heading":        int(report.Heading),
-						"airspeed":       fmt.Sprintf("%.0f", report.Airspeed),
-						"vertical_speed": fmt.Sprintf("%.0f", report.VerticalSpeed),
-						"flaps":          fmt.Sprintf("%.0f", report.Flaps),
-						"trim":           fmt.Sprintf("%.1f", report.Trim),
-						"rudder_trim":    fmt.Sprintf("%.1f", report.RudderTrim),
-					})
-
-					report.RequestData(s)
-				}
-
-			default:
-				fmt.Println("recvInfo.ID unknown", recvInfo.ID)
-			}
-
-			time.Sleep(100 * time.Millisecond)
-		}
-	}()
+	mux := newMux(ws, recorder, relayToken, exePath, mapApiKey)
 
 	go func() {
-		app := func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-			w.Header().Set("Pragma", "no-cache")
-			w.Header().Set("Expires", "0")
-			w.Header().Set("Content-Type", "text/html")
-
-			filePath := filepath.Join(filepath.Dir(exePath), "index.html")
-
-			var buf []byte
-			if _, err = os.Stat(filePath); os.IsNotExist(err) {
-				buf = MustAsset(filepath.Base(filePath))
-			} else {
-				fmt.Println("use local", filePath)
-				//http.ServeFile(w, r, filePath)
-				buf, _ = ioutil.ReadFile(filePath)
+		if autocertDomain != "" {
+			manager := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				HostPolicy: autocert.HostWhitelist(autocertDomain),
+				Cache:      autocert.DirCache(autocertCache),
 			}
 
-			buf = bytes.Replace(buf, []byte("{{API_KEY}}"), []byte(mapApiKey), -1)
-			w.Write(buf)
-		}
-
-		http.HandleFunc("/ws", ws.Serve)
-		http.HandleFunc("/", app)
-		//http.Handle("/", http.FileServer(http.Dir(".")))
+			// HTTP-01 challenge responder; Let's Encrypt must be able to
+			// reach this on :80 to issue/renew the certificate.
+			go func() {
+				if err := http.ListenAndServe(":80", manager.HTTPHandler(nil)); err != nil {
+					panic(err)
+				}
+			}()
 
-		err := http.ListenAndServe(httpListen, nil)
-		if err != nil {
-			panic(err)
+			server := &http.Server{
+				Addr:      httpListen,
+				Handler:   mux,
+				TLSConfig: manager.TLSConfig(),
+			}
+			fmt.Println("serving https via autocert for", autocertDomain, "on", httpListen)
+			if err := server.ListenAndServeTLS("", ""); err != nil {
+				panic(err)
+			}
+		} else if tlsCert != "" || tlsKey != "" {
+			fmt.Println("serving https on", httpListen)
+			if err := http.ListenAndServeTLS(httpListen, tlsCert, tlsKey, mux); err != nil {
+				panic(err)
+			}
+		} else {
+			if err := http.ListenAndServe(httpListen, mux); err != nil {
+				panic(err)
+			}
 		}
 	}()
 
@@ -201,8 +205,10 @@ func main() {
 
 		case <-exitSignal:
 			fmt.Println("exiting..")
-			if err = s.Close(); err != nil {
-				panic(err)
+			if s != nil {
+				if err := s.Close(); err != nil {
+					panic(err)
+				}
 			}
 			os.Exit(0)
 
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lian/msfs2020-go/simconnect/simmock"
+	"github.com/lian/msfs2020-go/vfrmap/tracklog"
+	"github.com/lian/msfs2020-go/vfrmap/websockets"
+)
+
+// TestHandleDispatchDrivesBroadcastsAndTrackExport replays
+// testdata/flight.json through handleDispatch exactly as the dispatch
+// loop in main would, and checks the result reaches both the websocket
+// broadcaster and the HTTP track export route.
+func TestHandleDispatchDrivesBroadcastsAndTrackExport(t *testing.T) {
+	client, err := simmock.New("testdata/flight.json")
+	if err != nil {
+		t.Fatalf("simmock.New: %s", err)
+	}
+
+	report := &Report{}
+	if err := client.RegisterDataDefinition(report); err != nil {
+		t.Fatalf("RegisterDataDefinition: %s", err)
+	}
+	reportDefineID := client.GetDefineID(report)
+	report.RequestData(client)
+
+	ws := websockets.New()
+
+	store, err := tracklog.NewStore("local:" + t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore: %s", err)
+	}
+	recorder := tracklog.NewRecorder(store)
+	if err := recorder.Open(); err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+
+	positions := make(chan websockets.Envelope, 8)
+	go func() {
+		for env := range ws.Broadcasts {
+			if env.Type == "position" {
+				positions <- env
+			}
+		}
+	}()
+
+	for {
+		report, err = handleDispatch(client, ws, recorder, reportDefineID, report, false)
+		if err == errNoDispatch {
+			break
+		}
+		if err != nil {
+			t.Fatalf("handleDispatch: %s", err)
+		}
+	}
+
+	select {
+	case <-positions:
+	case <-time.After(time.Second):
+		t.Fatal("expected the fixture to broadcast at least one position envelope")
+	}
+
+	if len(recorder.Current().Points) != 2 {
+		t.Fatalf("expected both fixture frames to be recorded, got %d points", len(recorder.Current().Points))
+	}
+
+	mux := newMux(ws, recorder, "", "", "")
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/track/current.gpx")
+	if err != nil {
+		t.Fatalf("GET /track/current.gpx: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from /track/current.gpx, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "gpx") {
+		t.Fatalf("expected a gpx content type, got %q", resp.Header.Get("Content-Type"))
+	}
+}
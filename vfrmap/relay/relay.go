@@ -0,0 +1,108 @@
+// Package relay implements the client side of a shared-cockpit relay
+// link: it dials a remote vfrmap running in -relay mode, authenticates
+// with a shared token, and republishes every local broadcast there so
+// viewers behind NAT can watch through a single public relay without
+// needing SimConnect themselves.
+package relay
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lian/msfs2020-go/vfrmap/websockets"
+)
+
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Upstream maintains a reconnecting websocket connection to a relay
+// server and forwards envelopes read from a channel there.
+type Upstream struct {
+	url   string
+	token string
+}
+
+// NewUpstream targets the relay server at rawurl (its /relay endpoint),
+// authenticating with token.
+func NewUpstream(rawurl, token string) *Upstream {
+	return &Upstream{url: rawurl, token: token}
+}
+
+// Run dials the relay server and forwards envelopes until stop is closed,
+// reconnecting with exponential backoff whenever the link drops.
+func (u *Upstream) Run(envelopes <-chan websockets.Envelope, stop <-chan struct{}) {
+	backoff := minBackoff
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		conn, err := u.dial()
+		if err != nil {
+			log.Println("relay: upstream dial failed, retrying in", backoff, ":", err)
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		log.Println("relay: connected upstream to", u.url)
+		backoff = minBackoff
+
+		if !u.forward(conn, envelopes, stop) {
+			return
+		}
+	}
+}
+
+func (u *Upstream) dial() (*websocket.Conn, error) {
+	target, err := url.Parse(u.url)
+	if err != nil {
+		return nil, fmt.Errorf("relay: invalid upstream url %q: %s", u.url, err)
+	}
+
+	q := target.Query()
+	q.Set("token", u.token)
+	target.RawQuery = q.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(target.String(), nil)
+	return conn, err
+}
+
+// forward writes envelopes to conn until the link breaks (returns true, so
+// Run reconnects) or stop is closed / envelopes is exhausted (returns
+// false, so Run exits for good).
+func (u *Upstream) forward(conn *websocket.Conn, envelopes <-chan websockets.Envelope, stop <-chan struct{}) bool {
+	defer conn.Close()
+
+	for {
+		select {
+		case <-stop:
+			return false
+		case env, ok := <-envelopes:
+			if !ok {
+				return false
+			}
+			if err := conn.WriteJSON(env); err != nil {
+				log.Println("relay: upstream write failed, reconnecting:", err)
+				return true
+			}
+		}
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,26 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextBackoffDoublesAndCapsAtMax(t *testing.T) {
+	d := minBackoff
+	for i := 0; i < 10; i++ {
+		d = nextBackoff(d)
+	}
+
+	if d != maxBackoff {
+		t.Fatalf("expected backoff to cap at %s, got %s", maxBackoff, d)
+	}
+}
+
+func TestNextBackoffDoublesBeforeReachingMax(t *testing.T) {
+	got := nextBackoff(minBackoff)
+	want := 2 * time.Second
+
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lian/msfs2020-go/vfrmap/tracklog"
+	"github.com/lian/msfs2020-go/vfrmap/websockets"
+)
+
+// newMux builds the HTTP routes vfrmap serves: the map UI, the websocket
+// hub, the relay endpoint, and (when recorder is non-nil, i.e. not
+// -relay mode) flight track export.
+func newMux(ws *websockets.Websockets, recorder *tracklog.Recorder, relayToken, exePath, mapApiKey string) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", appHandler(exePath, mapApiKey))
+	mux.HandleFunc("/ws", ws.Serve)
+	mux.HandleFunc("/relay", ws.RelayHandler(relayToken))
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ws.Peers())
+	})
+
+	if recorder != nil {
+		mux.HandleFunc("/track/current.gpx", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/gpx+xml")
+			w.Write(tracklog.ToGPX(recorder.Current()))
+		})
+		mux.HandleFunc("/track/current.kml", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+			w.Write(tracklog.ToKML(recorder.Current()))
+		})
+		mux.HandleFunc("/track/", trackHandler(recorder))
+	}
+
+	return mux
+}
+
+func appHandler(exePath, mapApiKey string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("Expires", "0")
+		w.Header().Set("Content-Type", "text/html")
+
+		filePath := filepath.Join(filepath.Dir(exePath), "index.html")
+
+		var buf []byte
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			buf = MustAsset(filepath.Base(filePath))
+		} else {
+			fmt.Println("use local", filePath)
+			buf, _ = ioutil.ReadFile(filePath)
+		}
+
+		buf = bytes.Replace(buf, []byte("{{API_KEY}}"), []byte(mapApiKey), -1)
+		w.Write(buf)
+	}
+}
+
+func trackHandler(recorder *tracklog.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/track/")
+		ext := filepath.Ext(name)
+		id := strings.TrimSuffix(name, ext)
+
+		track, err := recorder.Load(id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch ext {
+		case ".gpx":
+			w.Header().Set("Content-Type", "application/gpx+xml")
+			w.Write(tracklog.ToGPX(track))
+		case ".kml":
+			w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+			w.Write(tracklog.ToKML(track))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"unsafe"
+
+	"github.com/lian/msfs2020-go/simconnect"
+)
+
+// SimClient is the subset of *simconnect.SimConnect the dispatch loop
+// depends on. *simconnect.SimConnect satisfies it as-is; the
+// simconnect/simmock package provides a second implementation that
+// replays a scripted fixture, so the websocket broadcaster and HTTP
+// handler can be exercised without a running copy of MSFS.
+type SimClient interface {
+	RegisterDataDefinition(data interface{}) error
+	GetDefineID(data interface{}) simconnect.DWORD
+	RequestDataOnSimObjectType(requestID, defineID simconnect.DWORD, radiusMeters uint32, simObjectType simconnect.DWORD)
+	GetNextDispatch() (unsafe.Pointer, uintptr, error)
+	Close() error
+}
@@ -0,0 +1,85 @@
+package tracklog
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// azureStore stores one blob per track id in an Azure Blob Storage
+// container. Credentials come from the standard AZURE_STORAGE_ACCOUNT /
+// AZURE_STORAGE_ACCESS_KEY environment variables.
+type azureStore struct {
+	prefix    string
+	container azblob.ContainerURL
+}
+
+func newAzureStore(container, prefix string) (*azureStore, error) {
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	key := os.Getenv("AZURE_STORAGE_ACCESS_KEY")
+
+	credential, err := azblob.NewSharedKeyCredential(account, key)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	u, err := url.Parse("https://" + account + ".blob.core.windows.net/" + container)
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureStore{prefix: prefix, container: azblob.NewContainerURL(*u, pipeline)}, nil
+}
+
+func (s *azureStore) Save(id string, data []byte) error {
+	blob := s.container.NewBlockBlobURL(s.key(id))
+	_, err := azblob.UploadBufferToBlockBlob(context.Background(), data, blob, azblob.UploadToBlockBlobOptions{})
+	return err
+}
+
+func (s *azureStore) Load(id string) ([]byte, error) {
+	blob := s.container.NewBlockBlobURL(s.key(id))
+	resp, err := blob.Download(context.Background(), 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{})
+	defer body.Close()
+
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(buf)
+}
+
+func (s *azureStore) List() ([]string, error) {
+	var ids []string
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := s.container.ListBlobsFlatSegment(context.Background(), marker, azblob.ListBlobsSegmentOptions{Prefix: s.prefix})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Segment.BlobItems {
+			ids = append(ids, idFromKey(item.Name))
+		}
+
+		marker = resp.NextMarker
+	}
+	return ids, nil
+}
+
+func (s *azureStore) key(id string) string {
+	if s.prefix == "" {
+		return id + ".json"
+	}
+	return strings.TrimSuffix(s.prefix, "/") + "/" + id + ".json"
+}
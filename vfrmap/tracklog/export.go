@@ -0,0 +1,44 @@
+package tracklog
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// ToGPX renders a track as a single-segment GPX 1.1 track.
+func ToGPX(t Track) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprint(buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprint(buf, `<gpx version="1.1" creator="vfrmap" xmlns="http://www.topografix.com/GPX/1/1">`+"\n")
+	fmt.Fprintf(buf, "<trk><name>%s</name><trkseg>\n", t.ID)
+
+	for _, p := range t.Points {
+		fmt.Fprintf(buf, `<trkpt lat="%f" lon="%f"><ele>%f</ele><time>%s</time></trkpt>`+"\n",
+			p.Latitude, p.Longitude, metersFromFeet(p.Altitude), p.Time.UTC().Format(time.RFC3339))
+	}
+
+	fmt.Fprint(buf, "</trkseg></trk></gpx>\n")
+	return buf.Bytes()
+}
+
+// ToKML renders a track as a single KML LineString placemark.
+func ToKML(t Track) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprint(buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprint(buf, `<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`+"\n")
+	fmt.Fprintf(buf, "<name>%s</name>\n", t.ID)
+	fmt.Fprint(buf, "<Placemark><LineString><altitudeMode>absolute</altitudeMode><coordinates>\n")
+
+	for _, p := range t.Points {
+		fmt.Fprintf(buf, "%f,%f,%f\n", p.Longitude, p.Latitude, metersFromFeet(p.Altitude))
+	}
+
+	fmt.Fprint(buf, "</coordinates></LineString></Placemark>\n")
+	fmt.Fprint(buf, "</Document></kml>\n")
+	return buf.Bytes()
+}
+
+func metersFromFeet(feet float64) float64 {
+	return feet * 0.3048
+}
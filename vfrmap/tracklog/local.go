@@ -0,0 +1,51 @@
+package tracklog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// localStore keeps one JSON file per track id under a directory, e.g.
+// "local:/var/lib/vfrmap/tracks" -> /var/lib/vfrmap/tracks/<id>.json
+type localStore struct {
+	dir string
+}
+
+func newLocalStore(dir string) (*localStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &localStore{dir: dir}, nil
+}
+
+func (s *localStore) Save(id string, data []byte) error {
+	return ioutil.WriteFile(s.path(id), data, 0644)
+}
+
+func (s *localStore) Load(id string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(id))
+}
+
+func (s *localStore) List() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		ids = append(ids, entry.Name()[:len(entry.Name())-len(".json")])
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *localStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
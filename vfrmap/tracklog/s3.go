@@ -0,0 +1,89 @@
+package tracklog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Store stores one object per track id under bucket/prefix. Credentials
+// and region come from the usual AWS environment variables
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION, ...).
+type s3Store struct {
+	bucket string
+	prefix string
+	client *s3.S3
+}
+
+func newS3Store(bucket, prefix string) (*s3Store, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Store{bucket: bucket, prefix: prefix, client: s3.New(sess)}, nil
+}
+
+func (s *s3Store) Save(id string, data []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(id)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *s3Store) Load(id string) ([]byte, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(id)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return ioutil.ReadAll(out.Body)
+}
+
+func (s *s3Store) List() ([]string, error) {
+	var ids []string
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	}
+
+	for {
+		out, err := s.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range out.Contents {
+			ids = append(ids, idFromKey(*obj.Key))
+		}
+
+		if !aws.BoolValue(out.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+
+	return ids, nil
+}
+
+func (s *s3Store) key(id string) string {
+	return path.Join(s.prefix, id+".json")
+}
+
+func idFromKey(key string) string {
+	base := path.Base(key)
+	return base[:len(base)-len(path.Ext(base))]
+}
@@ -0,0 +1,53 @@
+package tracklog
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SnapStore persists track snapshots, named by track id, behind a
+// pluggable backend selected by URL scheme. Modeled on the snapstore
+// abstraction etcd's backup-restore tooling uses to support one interface
+// with local/S3/Swift/ABS drivers underneath.
+type SnapStore interface {
+	// Save writes (or overwrites) the full contents stored for id.
+	Save(id string, data []byte) error
+	// Load returns the full contents previously saved for id.
+	Load(id string) ([]byte, error)
+	// List returns known track ids, oldest first.
+	List() ([]string, error)
+}
+
+// NewStore builds the SnapStore named by rawurl's scheme:
+//
+//	local:/path/to/dir
+//	s3://bucket/prefix
+//	swift://container/prefix
+//	azure://container/prefix
+//
+// Credentials, region and account are taken from each provider's standard
+// SDK environment variables rather than flags.
+func NewStore(rawurl string) (SnapStore, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("tracklog: invalid -track-store %q: %s", rawurl, err)
+	}
+
+	switch u.Scheme {
+	case "", "local":
+		path := u.Opaque
+		if path == "" {
+			path = u.Path
+		}
+		return newLocalStore(path)
+	case "s3":
+		return newS3Store(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "swift":
+		return newSwiftStore(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "azure":
+		return newAzureStore(u.Host, strings.TrimPrefix(u.Path, "/"))
+	default:
+		return nil, fmt.Errorf("tracklog: unknown -track-store scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,67 @@
+package tracklog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/ncw/swift"
+)
+
+// swiftStore stores one object per track id in an OpenStack Swift
+// container. Auth comes from the standard ST_AUTH/ST_USER/ST_KEY
+// environment variables, plus OS_TENANT_NAME for the tenant; no other
+// OS_* variables are read.
+type swiftStore struct {
+	container string
+	prefix    string
+	conn      *swift.Connection
+}
+
+func newSwiftStore(container, prefix string) (*swiftStore, error) {
+	conn := &swift.Connection{
+		UserName: os.Getenv("ST_USER"),
+		ApiKey:   os.Getenv("ST_KEY"),
+		AuthUrl:  os.Getenv("ST_AUTH"),
+		Tenant:   os.Getenv("OS_TENANT_NAME"),
+	}
+
+	if err := conn.Authenticate(); err != nil {
+		return nil, err
+	}
+
+	return &swiftStore{container: container, prefix: prefix, conn: conn}, nil
+}
+
+func (s *swiftStore) Save(id string, data []byte) error {
+	return s.conn.ObjectPutBytes(s.container, s.key(id), data, "application/json")
+}
+
+func (s *swiftStore) Load(id string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	_, err := s.conn.ObjectGet(s.container, s.key(id), buf, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(buf)
+}
+
+func (s *swiftStore) List() ([]string, error) {
+	names, err := s.conn.ObjectNamesAll(s.container, &swift.ObjectsOpts{Prefix: s.prefix})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, name := range names {
+		ids = append(ids, idFromKey(name))
+	}
+	return ids, nil
+}
+
+func (s *swiftStore) key(id string) string {
+	if s.prefix == "" {
+		return id + ".json"
+	}
+	return s.prefix + "/" + id + ".json"
+}
@@ -0,0 +1,208 @@
+// Package tracklog records each simconnect Report produced by the
+// dispatch loop into a rolling flight track, and can export it as GPX or
+// KML on demand. Persistence is pluggable behind a SnapStore so a
+// -track-store flag is all callers need (see NewStore).
+package tracklog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Point is one sample of a flight track.
+type Point struct {
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Altitude  float64   `json:"altitude"`
+	Heading   float64   `json:"heading"`
+	Airspeed  float64   `json:"airspeed"`
+}
+
+// Track is a single flight's worth of points, identified by an id derived
+// from when it started.
+type Track struct {
+	ID     string  `json:"id"`
+	Points []Point `json:"points"`
+}
+
+// groundStationarySeconds is how long the aircraft has to sit on the
+// ground, stationary, before a track is considered finished and a new one
+// is started on the next report.
+const groundStationarySeconds = 60
+
+// onGroundAirspeed is the airspeed below which the aircraft is assumed to
+// be taxiing or parked rather than flying.
+const onGroundAirspeed = 5
+
+// groundAltitudeBandFeet is how much the altitude may drift from where the
+// aircraft first dropped below onGroundAirspeed and still count as sitting
+// still on the ground, rather than e.g. a slow, low pass.
+const groundAltitudeBandFeet = 50
+
+// Recorder appends reports to the current track and rotates/resumes
+// tracks behind a SnapStore.
+type Recorder struct {
+	store SnapStore
+
+	mu                 sync.Mutex
+	current            *Track
+	groundSince        time.Time
+	groundAltitude     float64
+	rotatedThisLanding bool
+}
+
+// NewRecorder builds a Recorder persisting tracks to store.
+func NewRecorder(store SnapStore) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Open resumes the most recent track if it never reached a landing
+// transition (i.e. the process was killed mid-flight), otherwise starts a
+// fresh one.
+func (r *Recorder) Open() error {
+	ids, err := r.store.List()
+	if err != nil {
+		return err
+	}
+
+	if len(ids) > 0 {
+		last := ids[len(ids)-1]
+		track, err := r.load(last)
+		if err != nil {
+			return err
+		}
+
+		if !landed(track) {
+			fmt.Println("tracklog: resuming unfinished flight", track.ID)
+			r.mu.Lock()
+			r.current = &track
+			r.mu.Unlock()
+			return nil
+		}
+	}
+
+	return r.start(newTrackID())
+}
+
+// landed reports whether a track's last samples look like they were on
+// the ground and settled, based on the same Airspeed/Altitude heuristic
+// Record uses to decide rotation: low airspeed and an altitude that has
+// stopped changing.
+func landed(t Track) bool {
+	if len(t.Points) == 0 {
+		return true
+	}
+
+	last := t.Points[len(t.Points)-1]
+	if last.Airspeed >= onGroundAirspeed {
+		return false
+	}
+
+	for i := len(t.Points) - 1; i >= 0 && last.Time.Sub(t.Points[i].Time) <= groundStationarySeconds*time.Second; i-- {
+		if math.Abs(t.Points[i].Altitude-last.Altitude) > groundAltitudeBandFeet {
+			return false
+		}
+	}
+	return true
+}
+
+func newTrackID() string {
+	// Microsecond precision so two rotations in the same wall-clock second
+	// (e.g. landing immediately followed by another take-off/landing) still
+	// get distinct ids.
+	return time.Now().UTC().Format("20060102-150405.000000")
+}
+
+func (r *Recorder) start(id string) error {
+	r.mu.Lock()
+	r.current = &Track{ID: id}
+	r.mu.Unlock()
+	return r.save()
+}
+
+// Record appends a point to the current track, saving it to the store and
+// rotating to a new track once the aircraft has landed and then been
+// stationary on the ground for long enough. Rotation only fires once per
+// landing: it's gated on rotatedThisLanding, which only clears once the
+// aircraft is airborne again, so staying parked at the gate doesn't keep
+// producing new, near-empty tracks every groundStationarySeconds.
+func (r *Recorder) Record(p Point) error {
+	r.mu.Lock()
+
+	switch {
+	case p.Airspeed >= onGroundAirspeed:
+		r.groundSince = time.Time{}
+		r.rotatedThisLanding = false
+
+	case r.groundSince.IsZero() || math.Abs(p.Altitude-r.groundAltitude) > groundAltitudeBandFeet:
+		// first grounded sample since take-off, or the altitude drifted too
+		// far to really be sitting still: (re)start the stationary clock.
+		r.groundSince = p.Time
+		r.groundAltitude = p.Altitude
+
+	case !r.rotatedThisLanding && len(r.current.Points) > 0 && p.Time.Sub(r.groundSince) >= groundStationarySeconds*time.Second:
+		r.rotatedThisLanding = true
+		r.mu.Unlock()
+		if err := r.start(newTrackID()); err != nil {
+			return err
+		}
+		r.mu.Lock()
+	}
+
+	r.current.Points = append(r.current.Points, p)
+	r.mu.Unlock()
+
+	return r.save()
+}
+
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	current := *r.current
+	r.mu.Unlock()
+
+	buf, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return r.store.Save(current.ID, buf)
+}
+
+// Current returns a snapshot of the in-progress track.
+func (r *Recorder) Current() Track {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.current
+}
+
+// validIDPattern matches the ids newTrackID generates: a sortable
+// timestamp, with or without the microsecond suffix older tracks may
+// have been saved without. Nothing in it can act as a path separator, so
+// it's safe to hand straight to a SnapStore backend.
+var validIDPattern = regexp.MustCompile(`^\d{8}-\d{6}(\.\d{6})?$`)
+
+// Load returns a previously recorded track by id. id comes straight from
+// callers like the HTTP handler, which only strip a file extension off a
+// URL path before passing it on, so it's validated against the format
+// newTrackID generates rather than handed to the store as-is.
+func (r *Recorder) Load(id string) (Track, error) {
+	if !validIDPattern.MatchString(id) {
+		return Track{}, fmt.Errorf("tracklog: invalid track id %q", id)
+	}
+	return r.load(id)
+}
+
+func (r *Recorder) load(id string) (Track, error) {
+	buf, err := r.store.Load(id)
+	if err != nil {
+		return Track{}, err
+	}
+
+	var t Track
+	err = json.Unmarshal(buf, &t)
+	return t, err
+}
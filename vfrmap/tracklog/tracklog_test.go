@@ -0,0 +1,139 @@
+package tracklog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordRotatesAfterLandingAndBeingStationary(t *testing.T) {
+	store, err := newLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("newLocalStore: %s", err)
+	}
+
+	r := NewRecorder(store)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	firstID := r.Current().ID
+
+	if err := r.Record(Point{Time: start, Airspeed: 90, Altitude: 2000}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+	grounded := start.Add(1 * time.Second)
+	if err := r.Record(Point{Time: grounded, Airspeed: 0, Altitude: 0}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+	if err := r.Record(Point{Time: grounded.Add((groundStationarySeconds + 1) * time.Second), Airspeed: 0, Altitude: 0}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+
+	rotatedID := r.Current().ID
+	if rotatedID == firstID {
+		t.Fatalf("expected a new track after being stationary on the ground for %ds", groundStationarySeconds)
+	}
+	if len(r.Current().Points) != 1 {
+		t.Fatalf("expected the rotated track to contain only the triggering point, got %d", len(r.Current().Points))
+	}
+
+	// Staying parked for further groundStationarySeconds windows must not
+	// keep splitting the track into more fragments.
+	for i := 1; i <= 3; i++ {
+		at := grounded.Add(time.Duration(groundStationarySeconds+1+i*groundStationarySeconds) * time.Second)
+		if err := r.Record(Point{Time: at, Airspeed: 0, Altitude: 0}); err != nil {
+			t.Fatalf("Record: %s", err)
+		}
+	}
+	if r.Current().ID != rotatedID {
+		t.Fatalf("expected the track to stay %s while parked, got %s", rotatedID, r.Current().ID)
+	}
+
+	// Taking off and landing again should allow exactly one more rotation.
+	airborne := grounded.Add(5 * groundStationarySeconds * time.Second)
+	if err := r.Record(Point{Time: airborne, Airspeed: 90, Altitude: 2000}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+	grounded2 := airborne.Add(1 * time.Second)
+	if err := r.Record(Point{Time: grounded2, Airspeed: 0, Altitude: 0}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+	if err := r.Record(Point{Time: grounded2.Add((groundStationarySeconds + 1) * time.Second), Airspeed: 0, Altitude: 0}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+
+	if r.Current().ID == rotatedID {
+		t.Fatalf("expected a second rotation after a second landing")
+	}
+}
+
+func TestOpenResumesUnfinishedFlight(t *testing.T) {
+	store, err := newLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("newLocalStore: %s", err)
+	}
+
+	r := NewRecorder(store)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	if err := r.Record(Point{Time: time.Now(), Airspeed: 110, Altitude: 3000}); err != nil {
+		t.Fatalf("Record: %s", err)
+	}
+	inFlightID := r.Current().ID
+
+	resumed := NewRecorder(store)
+	if err := resumed.Open(); err != nil {
+		t.Fatalf("Open (resumed): %s", err)
+	}
+
+	if resumed.Current().ID != inFlightID {
+		t.Fatalf("expected Open to resume track %s, got %s", inFlightID, resumed.Current().ID)
+	}
+	if len(resumed.Current().Points) != 1 {
+		t.Fatalf("expected the resumed track to keep its point, got %d", len(resumed.Current().Points))
+	}
+}
+
+func TestLoadRejectsIDsThatArentTrackIDs(t *testing.T) {
+	store, err := newLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("newLocalStore: %s", err)
+	}
+
+	r := NewRecorder(store)
+	if err := r.Open(); err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+
+	for _, id := range []string{
+		"../../etc/passwd",
+		"..",
+		"foo/bar",
+		"foo\\bar",
+		"",
+	} {
+		if _, err := r.Load(id); err == nil {
+			t.Fatalf("expected Load(%q) to be rejected", id)
+		}
+	}
+}
+
+func TestToGPXIncludesEachPoint(t *testing.T) {
+	track := Track{
+		ID: "20260101-120000",
+		Points: []Point{
+			{Time: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), Latitude: 47.449, Longitude: -122.309, Altitude: 1000},
+		},
+	}
+
+	gpx := string(ToGPX(track))
+	if !strings.Contains(gpx, `lat="47.449000"`) {
+		t.Fatalf("expected latitude in gpx output, got %s", gpx)
+	}
+	if !strings.Contains(gpx, "<name>20260101-120000</name>") {
+		t.Fatalf("expected track id in gpx output, got %s", gpx)
+	}
+}
@@ -0,0 +1,13 @@
+package websockets
+
+// Envelope is the typed message every client (map UI or relay peer)
+// exchanges over the websocket, replacing the old untyped
+// map[string]interface{} broadcast. Seq increments once per broadcast so
+// a client reconnecting after a relay hiccup can tell whether it missed
+// anything.
+type Envelope struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+	Seq     uint64      `json:"seq"`
+	Ts      int64       `json:"ts"`
+}
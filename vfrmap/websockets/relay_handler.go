@@ -0,0 +1,29 @@
+package websockets
+
+import "net/http"
+
+// RelayHandler accepts the authenticated upstream link from a vfrmap
+// instance connected to MSFS and republishes every envelope it sends to
+// this hub's downstream clients, i.e. -relay mode's fan-out side.
+func (w *Websockets) RelayHandler(token string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if token != "" && r.URL.Query().Get("token") != token {
+			http.Error(rw, "invalid relay token", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := w.upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var env Envelope
+			if err := conn.ReadJSON(&env); err != nil {
+				return
+			}
+			w.BroadcastEnvelope(env)
+		}
+	}
+}
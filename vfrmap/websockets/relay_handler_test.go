@@ -0,0 +1,43 @@
+package websockets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRelayHandlerRejectsWrongToken(t *testing.T) {
+	ws := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/relay", ws.RelayHandler("secret"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := "ws" + server.URL[len("http"):] + "/relay?token=wrong"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("expected the handshake to be rejected with the wrong token")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 response, got %+v", resp)
+	}
+}
+
+func TestRelayHandlerAcceptsCorrectToken(t *testing.T) {
+	ws := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/relay", ws.RelayHandler("secret"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := "ws" + server.URL[len("http"):] + "/relay?token=secret"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("expected the handshake to succeed with the right token: %s", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,183 @@
+// Package websockets fans out typed Envelope messages to every connected
+// map UI (and, in relay mode, to every downstream relay peer). A
+// connection can ask to only receive certain topics via ?topic=, and is
+// rate-limited both on the messages it sends upstream and on the
+// broadcasts it's fanned out, so one chatty or slow client can't hurt
+// everyone else.
+package websockets
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
+)
+
+// clientMessages caps how many inbound messages per second a single
+// connection may send before extras are dropped.
+const clientMessages = 20
+
+// broadcastMessages caps how many fanned-out messages per second a single
+// connection is sent before extras are dropped, so a slow or malicious
+// downstream client can't make the hub buffer (or block) on its behalf.
+const broadcastMessages = 50
+
+// Conn is one connected client.
+type Conn struct {
+	ws        *websocket.Conn
+	send      chan Envelope
+	limiter   *rate.Limiter   // throttles messages this connection sends upstream
+	broadcast *rate.Limiter   // throttles messages broadcast out to this connection
+	topics    map[string]bool // empty: subscribed to every topic
+	Remote    string
+}
+
+// Websockets is a hub of connected clients.
+type Websockets struct {
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[*Conn]bool
+	seq   uint64
+
+	// NewConnection receives every Conn as soon as it's accepted.
+	NewConnection chan *Conn
+	// ReceiveMessages receives every Envelope read back from a client,
+	// except those dropped for exceeding that client's clientMessages rate.
+	ReceiveMessages chan Envelope
+	// Broadcasts mirrors every Envelope sent via Broadcast/BroadcastEnvelope,
+	// so a relay upstream link can republish it elsewhere. Envelopes are
+	// dropped rather than queued while its buffer is full.
+	Broadcasts chan Envelope
+}
+
+// New returns an empty hub ready to Serve connections.
+func New() *Websockets {
+	return &Websockets{
+		upgrader:        websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		conns:           map[*Conn]bool{},
+		NewConnection:   make(chan *Conn, 8),
+		ReceiveMessages: make(chan Envelope, 8),
+		Broadcasts:      make(chan Envelope, 64),
+	}
+}
+
+// Serve upgrades r to a websocket and registers it as a downstream client,
+// filtered to ?topic=a&topic=b if given, until it disconnects.
+func (w *Websockets) Serve(rw http.ResponseWriter, r *http.Request) {
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &Conn{
+		ws:        conn,
+		send:      make(chan Envelope, 16),
+		limiter:   rate.NewLimiter(rate.Limit(clientMessages), clientMessages),
+		broadcast: rate.NewLimiter(rate.Limit(broadcastMessages), broadcastMessages),
+		Remote:    r.RemoteAddr,
+	}
+
+	if topics := r.URL.Query()["topic"]; len(topics) > 0 {
+		c.topics = map[string]bool{}
+		for _, topic := range topics {
+			c.topics[topic] = true
+		}
+	}
+
+	w.add(c)
+	w.NewConnection <- c
+
+	go w.writePump(c)
+	w.readPump(c)
+}
+
+func (w *Websockets) add(c *Conn) {
+	w.mu.Lock()
+	w.conns[c] = true
+	w.mu.Unlock()
+}
+
+func (w *Websockets) remove(c *Conn) {
+	w.mu.Lock()
+	if w.conns[c] {
+		delete(w.conns, c)
+		close(c.send)
+	}
+	w.mu.Unlock()
+}
+
+func (w *Websockets) readPump(c *Conn) {
+	defer w.remove(c)
+
+	for {
+		var env Envelope
+		if err := c.ws.ReadJSON(&env); err != nil {
+			return
+		}
+
+		if !c.limiter.Allow() {
+			continue // client is over its rate limit, drop the message
+		}
+
+		w.ReceiveMessages <- env
+	}
+}
+
+func (w *Websockets) writePump(c *Conn) {
+	for env := range c.send {
+		if err := c.ws.WriteJSON(env); err != nil {
+			c.ws.Close()
+			return
+		}
+	}
+}
+
+// Broadcast wraps payload in a new Envelope under topic and fans it out
+// to every connection subscribed to that topic.
+func (w *Websockets) Broadcast(topic string, payload interface{}) {
+	w.mu.Lock()
+	w.seq++
+	env := Envelope{Type: topic, Payload: payload, Seq: w.seq, Ts: time.Now().Unix()}
+	w.mu.Unlock()
+
+	w.BroadcastEnvelope(env)
+}
+
+// BroadcastEnvelope fans out an already-built Envelope as-is, e.g. one
+// relayed upstream from another vfrmap instance.
+func (w *Websockets) BroadcastEnvelope(env Envelope) {
+	w.mu.Lock()
+	for c := range w.conns {
+		if len(c.topics) > 0 && !c.topics[env.Type] {
+			continue
+		}
+		if !c.broadcast.Allow() {
+			continue // this connection is over its broadcast rate, drop rather than buffer
+		}
+		select {
+		case c.send <- env:
+		default: // slow consumer, drop rather than block the broadcaster
+		}
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.Broadcasts <- env:
+	default: // nobody relaying upstream, drop rather than block
+	}
+}
+
+// Peers returns the remote address of every currently connected client.
+func (w *Websockets) Peers() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	peers := make([]string, 0, len(w.conns))
+	for c := range w.conns {
+		peers = append(peers, c.Remote)
+	}
+	return peers
+}
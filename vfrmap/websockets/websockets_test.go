@@ -0,0 +1,92 @@
+package websockets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dial(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	if query != "" {
+		url += "?" + query
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %s", url, err)
+	}
+	return conn
+}
+
+func TestBroadcastOnlyReachesSubscribedTopics(t *testing.T) {
+	ws := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ws.Serve)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	position := dial(t, server, "topic=position")
+	defer position.Close()
+	controls := dial(t, server, "topic=controls")
+	defer controls.Close()
+
+	// give the server goroutines a moment to register both connections
+	time.Sleep(50 * time.Millisecond)
+
+	ws.Broadcast("position", map[string]float64{"latitude": 47.449})
+
+	var env Envelope
+	position.SetReadDeadline(time.Now().Add(time.Second))
+	if err := position.ReadJSON(&env); err != nil {
+		t.Fatalf("position subscriber should have received the broadcast: %s", err)
+	}
+	if env.Type != "position" || env.Seq != 1 {
+		t.Fatalf("unexpected envelope: %+v", env)
+	}
+
+	controls.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if err := controls.ReadJSON(&env); err == nil {
+		t.Fatalf("controls subscriber should not have received a position broadcast")
+	}
+}
+
+func TestBroadcastRateLimitsASlowConnection(t *testing.T) {
+	ws := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ws.Serve)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conn := dial(t, server, "")
+	defer conn.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	const sent = broadcastMessages + 20
+	for i := 0; i < sent; i++ {
+		ws.Broadcast("position", map[string]float64{"latitude": 47.449})
+	}
+
+	received := 0
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		var env Envelope
+		if err := conn.ReadJSON(&env); err != nil {
+			break
+		}
+		received++
+	}
+
+	if received >= sent {
+		t.Fatalf("expected the broadcast rate limit to drop some of %d messages sent in a burst, got all %d", sent, received)
+	}
+}